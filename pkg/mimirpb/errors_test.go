@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package mimirpb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorDetails_RoundTrip(t *testing.T) {
+	orig := &ErrorDetails{Cause: BAD_DATA, Source: SOURCE_DOWNSTREAM}
+
+	data, err := orig.Marshal()
+	require.NoError(t, err)
+
+	var decoded ErrorDetails
+	require.NoError(t, decoded.Unmarshal(data))
+	require.True(t, orig.Equal(&decoded))
+}
+
+func TestErrorDetails_UnmarshalSkipsUnknownFields(t *testing.T) {
+	// Simulates a message produced by a newer binary that knows about a
+	// field this version doesn't: an older decoder must skip it rather than
+	// fail, so a rolling upgrade doesn't turn into dropped error decoding.
+	known := &ErrorDetails{Cause: BAD_DATA}
+	knownData, err := known.Marshal()
+	require.NoError(t, err)
+
+	unknownVarintField := []byte{0x18, 0x2a}                    // field 3, wire type 0 (varint), value 42
+	unknownLengthDelimited := []byte{0x22, 0x03, 'f', 'o', 'o'} // field 4, wire type 2, "foo"
+
+	data := append(append(append([]byte{}, knownData...), unknownVarintField...), unknownLengthDelimited...)
+
+	var decoded ErrorDetails
+	require.NoError(t, decoded.Unmarshal(data))
+	require.Equal(t, BAD_DATA, decoded.Cause)
+}
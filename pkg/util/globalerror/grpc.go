@@ -0,0 +1,350 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package globalerror
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	rpcstatus "github.com/gogo/status"
+	"github.com/grafana/dskit/grpcutil"
+	"github.com/grafana/dskit/middleware"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+// ErrorWithStatus is an error that wraps an origin error, augmenting it with
+// a gRPC status built from a code and, optionally, a *mimirpb.ErrorDetails.
+// It implements the interfaces expected by gogo's, dskit's and grpc's own
+// status.FromError(), so it can cross the wire as a standard gRPC status.
+type ErrorWithStatus struct {
+	err       error
+	status    *rpcstatus.Status
+	logFields []any
+}
+
+// NewErrorWithGRPCStatus creates a new ErrorWithStatus backed by err, with
+// the given gRPC code, optionally enriched with ErrorDetails which is
+// attached to the returned gRPC status as a status detail.
+func NewErrorWithGRPCStatus(err error, code codes.Code, details *mimirpb.ErrorDetails) ErrorWithStatus {
+	return NewErrorWithGRPCStatusDetails(err, code, details)
+}
+
+// NewErrorWithGRPCStatusDetails is like NewErrorWithGRPCStatus, but also
+// accepts any number of additional typed detail messages (for example
+// *errdetails.RetryInfo, *errdetails.BadRequest or *errdetails.QuotaFailure
+// from google.golang.org/genproto/googleapis/rpc/errdetails) to attach to
+// the outgoing gRPC status alongside the Mimir-specific ErrorDetails.
+func NewErrorWithGRPCStatusDetails(err error, code codes.Code, details *mimirpb.ErrorDetails, extra ...proto.Message) ErrorWithStatus {
+	std := rpcstatus.New(code, err.Error())
+
+	all := make([]proto.Message, 0, len(extra)+1)
+	if details != nil {
+		all = append(all, details)
+	}
+	all = append(all, extra...)
+
+	if len(all) > 0 {
+		if withDetails, errWithDetails := std.WithDetails(all...); errWithDetails == nil {
+			std = withDetails
+		}
+	}
+	return ErrorWithStatus{
+		err:    err,
+		status: std,
+	}
+}
+
+// FromError is the symmetric counterpart of NewErrorWithGRPCStatus: given an
+// error carrying a gRPC status (as returned by grpcutil.ErrorToStatus), it
+// unmarshals the first *mimirpb.ErrorDetails found among the status details
+// and reconstructs an *ErrorWithStatus preserving the original message, code
+// and ErrorDetails, so that callers don't have to poke around stat.Details()
+// and type-assert by hand. The second return value is false if err doesn't
+// carry a gRPC status.
+func FromError(err error) (*ErrorWithStatus, bool) {
+	stat, ok := grpcutil.ErrorToStatus(err)
+	if !ok {
+		return nil, false
+	}
+
+	// stat already carries whatever details (including any *mimirpb.ErrorDetails)
+	// the original error had; reusing it as-is avoids re-attaching them and
+	// ending up with a duplicate entry.
+	reconstructed := &ErrorWithStatus{
+		err:    errors.New(stat.Message()),
+		status: stat,
+	}
+	return reconstructed, true
+}
+
+// errorDetailsFromStatus returns the first *mimirpb.ErrorDetails found among
+// stat's details, or nil if there isn't one.
+func errorDetailsFromStatus(stat *rpcstatus.Status) *mimirpb.ErrorDetails {
+	for _, d := range stat.Details() {
+		if details, ok := d.(*mimirpb.ErrorDetails); ok {
+			return details
+		}
+	}
+	return nil
+}
+
+// Error implements error.
+func (e ErrorWithStatus) Error() string {
+	return e.status.Message()
+}
+
+// GRPCStatus is used by gogo's, dskit's and grpc's status.FromError() to
+// recognize this error as a gRPC status-carrying error.
+func (e ErrorWithStatus) GRPCStatus() *rpcstatus.Status {
+	return e.status
+}
+
+// Unwrap allows errors.Is/errors.As to reach the original error.
+func (e ErrorWithStatus) Unwrap() error {
+	return e.err
+}
+
+// ShouldLog implements middleware.OptionalLogging, delegating to the
+// wrapped error when it opts out of logging (e.g. middleware.DoNotLogError).
+func (e ErrorWithStatus) ShouldLog(ctx context.Context, duration time.Duration) bool {
+	optional, ok := e.err.(middleware.OptionalLogging)
+	if !ok {
+		return true
+	}
+	return optional.ShouldLog(ctx, duration)
+}
+
+// LogFields returns the key/value pairs attached to this error via
+// WithLogFields, in a form consumable by the logging middleware (e.g.
+// level.Error(logger).Log(err.LogFields()...)).
+func (e *ErrorWithStatus) LogFields() []any {
+	return e.logFields
+}
+
+// WithDetails attaches details to the gRPC status carried by e, without
+// changing its code or message, replacing any *mimirpb.ErrorDetails already
+// present instead of appending a second one: gogo/status accumulates details
+// rather than overwriting them, so without this a later WithDetails/WithSource
+// call would leave the stale entry in place for errorDetailsFromStatus (which
+// returns the first match) to keep returning. It returns e for chaining.
+func (e *ErrorWithStatus) WithDetails(details *mimirpb.ErrorDetails) *ErrorWithStatus {
+	if details == nil {
+		return e
+	}
+	kept := make([]proto.Message, 0, len(e.status.Details()))
+	for _, d := range e.status.Details() {
+		if _, ok := d.(*mimirpb.ErrorDetails); ok {
+			continue
+		}
+		if msg, ok := d.(proto.Message); ok {
+			kept = append(kept, msg)
+		}
+	}
+	kept = append(kept, details)
+
+	fresh := rpcstatus.New(e.status.Code(), e.status.Message())
+	if withDetails, err := fresh.WithDetails(kept...); err == nil {
+		e.status = withDetails
+	}
+	return e
+}
+
+// WithSource stamps source onto e's ErrorDetails, creating one if e doesn't
+// already carry one, so that a component fanning out to several peers can
+// tell apart its own bugs from a peer's bugs or a downstream timeout. It
+// returns e for chaining.
+func (e *ErrorWithStatus) WithSource(source mimirpb.Source) *ErrorWithStatus {
+	details := errorDetailsFromStatus(e.status)
+	if details == nil {
+		details = &mimirpb.ErrorDetails{}
+	} else {
+		details = &mimirpb.ErrorDetails{Cause: details.Cause}
+	}
+	details.Source = source
+	return e.WithDetails(details)
+}
+
+// GetSource returns the mimirpb.Source carried by e's ErrorDetails, or
+// mimirpb.SOURCE_UNSPECIFIED if e doesn't carry one.
+func (e ErrorWithStatus) GetSource() mimirpb.Source {
+	if details := errorDetailsFromStatus(e.status); details != nil {
+		return details.Source
+	}
+	return mimirpb.SOURCE_UNSPECIFIED
+}
+
+// RequestStatus classifies the outcome of a request for use as a metrics
+// label, collapsing a gRPC code and an optional ErrorDetails.Source into a
+// single dimension that tells a cancellation, a caller's own mistake, a
+// bug in the immediate peer and a downstream failure apart.
+type RequestStatus string
+
+const (
+	RequestStatusOK              RequestStatus = "OK"
+	RequestStatusCancelled       RequestStatus = "Cancelled"
+	RequestStatusClientError     RequestStatus = "ClientError"
+	RequestStatusServerError     RequestStatus = "ServerError"
+	RequestStatusDownstreamError RequestStatus = "DownstreamError"
+)
+
+// clientCausedCodes are codes attributed to the caller rather than the
+// callee, used as a fallback by RequestStatusFromError when the error
+// doesn't carry an explicit mimirpb.Source.
+var clientCausedCodes = map[codes.Code]bool{
+	codes.InvalidArgument:    true,
+	codes.NotFound:           true,
+	codes.AlreadyExists:      true,
+	codes.FailedPrecondition: true,
+	codes.PermissionDenied:   true,
+	codes.Unauthenticated:    true,
+}
+
+// RequestStatusFromError classifies err for use in a metrics label. If err
+// carries ErrorDetails with an explicit Source, that takes precedence over
+// the gRPC code, so that e.g. a querier can record a store-gateway's
+// Internal error as DownstreamError rather than indistinguishably as
+// ServerError.
+func RequestStatusFromError(err error) RequestStatus {
+	if err == nil {
+		return RequestStatusOK
+	}
+
+	stat, ok := grpcutil.ErrorToStatus(err)
+	if !ok {
+		return RequestStatusServerError
+	}
+	if stat.Code() == codes.Canceled {
+		return RequestStatusCancelled
+	}
+
+	if details := errorDetailsFromStatus(stat); details != nil {
+		switch details.Source {
+		case mimirpb.SOURCE_CLIENT:
+			return RequestStatusClientError
+		case mimirpb.SOURCE_SERVER:
+			return RequestStatusServerError
+		case mimirpb.SOURCE_DOWNSTREAM:
+			return RequestStatusDownstreamError
+		}
+	}
+
+	if clientCausedCodes[stat.Code()] {
+		return RequestStatusClientError
+	}
+	return RequestStatusServerError
+}
+
+// WithLogFields attaches key/value metadata to e, to be surfaced by the
+// logging middleware via LogFields(). It returns e for chaining.
+func (e *ErrorWithStatus) WithLogFields(keyvals ...any) *ErrorWithStatus {
+	e.logFields = append(e.logFields, keyvals...)
+	return e
+}
+
+// WithCause chains cause as the wrapped error reachable via errors.Unwrap,
+// without changing e's gRPC code: the code picked by the typed constructor
+// always wins, the cause is only kept around for logging and errors.Is/As.
+// It returns e for chaining.
+func (e *ErrorWithStatus) WithCause(cause error) *ErrorWithStatus {
+	e.err = cause
+	return e
+}
+
+func newTypedError(code codes.Code, format string, args ...any) *ErrorWithStatus {
+	msg := fmt.Sprintf(format, args...)
+	std := rpcstatus.New(code, msg)
+	return &ErrorWithStatus{
+		err:    errors.New(msg),
+		status: std,
+	}
+}
+
+// NewInvalidArgument returns an *ErrorWithStatus pre-bound to codes.InvalidArgument.
+func NewInvalidArgument(format string, args ...any) *ErrorWithStatus {
+	return newTypedError(codes.InvalidArgument, format, args...)
+}
+
+// NewInternal returns an *ErrorWithStatus pre-bound to codes.Internal.
+func NewInternal(format string, args ...any) *ErrorWithStatus {
+	return newTypedError(codes.Internal, format, args...)
+}
+
+// NewFailedPrecondition returns an *ErrorWithStatus pre-bound to codes.FailedPrecondition.
+func NewFailedPrecondition(format string, args ...any) *ErrorWithStatus {
+	return newTypedError(codes.FailedPrecondition, format, args...)
+}
+
+// NewAborted returns an *ErrorWithStatus pre-bound to codes.Aborted.
+func NewAborted(format string, args ...any) *ErrorWithStatus {
+	return newTypedError(codes.Aborted, format, args...)
+}
+
+// NewNotFound returns an *ErrorWithStatus pre-bound to codes.NotFound.
+func NewNotFound(format string, args ...any) *ErrorWithStatus {
+	return newTypedError(codes.NotFound, format, args...)
+}
+
+// NewResourceExhausted returns an *ErrorWithStatus pre-bound to codes.ResourceExhausted.
+func NewResourceExhausted(format string, args ...any) *ErrorWithStatus {
+	return newTypedError(codes.ResourceExhausted, format, args...)
+}
+
+// NewUnavailable returns an *ErrorWithStatus pre-bound to codes.Unavailable.
+func NewUnavailable(format string, args ...any) *ErrorWithStatus {
+	return newTypedError(codes.Unavailable, format, args...)
+}
+
+// NewCanceled returns an *ErrorWithStatus pre-bound to codes.Canceled.
+func NewCanceled(format string, args ...any) *ErrorWithStatus {
+	return newTypedError(codes.Canceled, format, args...)
+}
+
+// NewDeadlineExceeded returns an *ErrorWithStatus pre-bound to codes.DeadlineExceeded.
+func NewDeadlineExceeded(format string, args ...any) *ErrorWithStatus {
+	return newTypedError(codes.DeadlineExceeded, format, args...)
+}
+
+// WrapGrpcContextError wraps a gRPC status error whose code is Canceled or
+// DeadlineExceeded with the corresponding context error (context.Canceled or
+// context.DeadlineExceeded), so that errors.Is() can recognize it. If err is
+// not a gRPC status error with one of these codes, it's returned unmodified.
+func WrapGrpcContextError(err error) error {
+	switch grpcutil.ErrorToStatusCode(err) {
+	case codes.Canceled:
+		return &contextError{wrapped: err, context: context.Canceled}
+	case codes.DeadlineExceeded:
+		return &contextError{wrapped: err, context: context.DeadlineExceeded}
+	default:
+		return err
+	}
+}
+
+// contextError decorates a gRPC status error with the context error it
+// originated from, while still exposing the original gRPC status via
+// GRPCStatus(), so downstream status.FromError() callers keep working.
+type contextError struct {
+	wrapped error
+	context error
+}
+
+func (e *contextError) Error() string {
+	return e.wrapped.Error()
+}
+
+func (e *contextError) Unwrap() error {
+	return e.wrapped
+}
+
+func (e *contextError) Is(target error) bool {
+	return target == e.context
+}
+
+func (e *contextError) GRPCStatus() *rpcstatus.Status {
+	stat, _ := grpcutil.ErrorToStatus(e.wrapped)
+	return stat
+}
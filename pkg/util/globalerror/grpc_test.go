@@ -6,6 +6,7 @@ import (
 	"context"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/gogo/status"
 	"github.com/grafana/dskit/grpcutil"
@@ -14,8 +15,10 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
 )
@@ -184,6 +187,179 @@ func TestErrorWithStatus(t *testing.T) {
 	}
 }
 
+func TestTypedConstructors(t *testing.T) {
+	tests := map[string]struct {
+		build        func() *ErrorWithStatus
+		expectedCode codes.Code
+		expectedMsg  string
+	}{
+		"NewInvalidArgument formats its message": {
+			build:        func() *ErrorWithStatus { return NewInvalidArgument("bad field %q", "foo") },
+			expectedCode: codes.InvalidArgument,
+			expectedMsg:  `bad field "foo"`,
+		},
+		"NewInternal formats its message": {
+			build:        func() *ErrorWithStatus { return NewInternal("boom") },
+			expectedCode: codes.Internal,
+			expectedMsg:  "boom",
+		},
+		"NewUnavailable formats its message": {
+			build:        func() *ErrorWithStatus { return NewUnavailable("downstream %s unreachable", "ingester") },
+			expectedCode: codes.Unavailable,
+			expectedMsg:  "downstream ingester unreachable",
+		},
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := data.build()
+			require.Error(t, err)
+			assert.Equal(t, data.expectedMsg, err.Error())
+
+			stat, ok := grpcstatus.FromError(err)
+			require.True(t, ok)
+			assert.Equal(t, data.expectedCode, stat.Code())
+		})
+	}
+
+	t.Run("WithDetails attaches ErrorDetails to the status", func(t *testing.T) {
+		details := &mimirpb.ErrorDetails{Cause: mimirpb.BAD_DATA}
+		err := NewInvalidArgument("bad data").WithDetails(details)
+
+		stat, ok := grpcstatus.FromError(err)
+		require.True(t, ok)
+		checkErrorWithStatusDetails(t, stat.Details(), details)
+	})
+
+	t.Run("WithLogFields accumulates key/value pairs", func(t *testing.T) {
+		err := NewInternal("boom").WithLogFields("user", "123").WithLogFields("retry", true)
+		assert.Equal(t, []any{"user", "123", "retry", true}, err.LogFields())
+	})
+
+	t.Run("WithCause preserves the outer code but keeps the cause reachable via errors.Unwrap", func(t *testing.T) {
+		cause := errors.New("root cause")
+		err := NewUnavailable("store-gateway unreachable").WithCause(cause)
+
+		assert.ErrorIs(t, err, cause)
+
+		stat, ok := grpcstatus.FromError(err)
+		require.True(t, ok)
+		assert.Equal(t, codes.Unavailable, stat.Code())
+	})
+
+	t.Run("WithSource replaces rather than duplicates an existing ErrorDetails", func(t *testing.T) {
+		err := NewInternal("boom").
+			WithDetails(&mimirpb.ErrorDetails{Cause: mimirpb.BAD_DATA}).
+			WithSource(mimirpb.SOURCE_DOWNSTREAM)
+
+		stat, ok := grpcstatus.FromError(err)
+		require.True(t, ok)
+		checkErrorWithStatusDetails(t, stat.Details(), &mimirpb.ErrorDetails{Cause: mimirpb.BAD_DATA, Source: mimirpb.SOURCE_DOWNSTREAM})
+		assert.Equal(t, mimirpb.SOURCE_DOWNSTREAM, err.GetSource())
+	})
+
+	t.Run("a second WithSource call replaces the first instead of appending", func(t *testing.T) {
+		err := NewInternal("boom").WithSource(mimirpb.SOURCE_SERVER).WithSource(mimirpb.SOURCE_DOWNSTREAM)
+
+		stat, ok := grpcstatus.FromError(err)
+		require.True(t, ok)
+		checkErrorWithStatusDetails(t, stat.Details(), &mimirpb.ErrorDetails{Source: mimirpb.SOURCE_DOWNSTREAM})
+		assert.Equal(t, mimirpb.SOURCE_DOWNSTREAM, err.GetSource())
+	})
+}
+
+func TestFromError(t *testing.T) {
+	t.Run("reconstructs an ErrorWithStatus from a status-carrying error", func(t *testing.T) {
+		details := &mimirpb.ErrorDetails{Cause: mimirpb.BAD_DATA}
+		sent := NewErrorWithGRPCStatus(errors.New("this is an error"), codes.InvalidArgument, details)
+
+		received, ok := FromError(sent)
+		require.True(t, ok)
+		assert.Equal(t, "this is an error", received.Error())
+
+		stat, ok := grpcstatus.FromError(received)
+		require.True(t, ok)
+		assert.Equal(t, codes.InvalidArgument, stat.Code())
+		checkErrorWithStatusDetails(t, stat.Details(), details)
+	})
+
+	t.Run("returns false on a non-gRPC error", func(t *testing.T) {
+		received, ok := FromError(errors.New("not a status error"))
+		assert.False(t, ok)
+		assert.Nil(t, received)
+	})
+
+	t.Run("round-tripping through FromError does not duplicate ErrorDetails", func(t *testing.T) {
+		details := &mimirpb.ErrorDetails{Cause: mimirpb.BAD_DATA}
+		sent := NewErrorWithGRPCStatus(errors.New("boom"), codes.Internal, details)
+
+		received, ok := FromError(sent)
+		require.True(t, ok)
+
+		reRoundTripped, ok := FromError(received)
+		require.True(t, ok)
+
+		stat, ok := grpcstatus.FromError(reRoundTripped)
+		require.True(t, ok)
+		checkErrorWithStatusDetails(t, stat.Details(), details)
+	})
+}
+
+func TestNewErrorWithGRPCStatusDetails(t *testing.T) {
+	details := &mimirpb.ErrorDetails{Cause: mimirpb.BAD_DATA}
+	retryInfo := &errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)}
+
+	err := NewErrorWithGRPCStatusDetails(errors.New("rate limited"), codes.ResourceExhausted, details, retryInfo)
+
+	stat, ok := grpcstatus.FromError(err)
+	require.True(t, ok)
+	require.Len(t, stat.Details(), 2)
+	assert.Equal(t, details, stat.Details()[0])
+	assert.Equal(t, retryInfo, stat.Details()[1])
+}
+
+func TestRequestStatusFromError(t *testing.T) {
+	tests := map[string]struct {
+		err            error
+		expectedStatus RequestStatus
+	}{
+		"nil error is OK": {
+			err:            nil,
+			expectedStatus: RequestStatusOK,
+		},
+		"Canceled is Cancelled regardless of source": {
+			err:            NewCanceled("client went away"),
+			expectedStatus: RequestStatusCancelled,
+		},
+		"InvalidArgument with no source is a ClientError": {
+			err:            NewInvalidArgument("bad query"),
+			expectedStatus: RequestStatusClientError,
+		},
+		"Internal with no source is a ServerError": {
+			err:            NewInternal("boom"),
+			expectedStatus: RequestStatusServerError,
+		},
+		"Internal with SOURCE_DOWNSTREAM is a DownstreamError": {
+			err:            NewInternal("store-gateway exploded").WithSource(mimirpb.SOURCE_DOWNSTREAM),
+			expectedStatus: RequestStatusDownstreamError,
+		},
+		"Internal with SOURCE_CLIENT is a ClientError": {
+			err:            NewInternal("bad request shape").WithSource(mimirpb.SOURCE_CLIENT),
+			expectedStatus: RequestStatusClientError,
+		},
+		"a plain, non-gRPC error is a ServerError": {
+			err:            errors.New("something went wrong"),
+			expectedStatus: RequestStatusServerError,
+		},
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, data.expectedStatus, RequestStatusFromError(data.err))
+		})
+	}
+}
+
 func checkErrorWithStatusDetails(t *testing.T, details []any, expected *mimirpb.ErrorDetails) {
 	if expected == nil {
 		require.Empty(t, details)
@@ -0,0 +1,334 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package grpcclient
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	dskitgrpcclient "github.com/grafana/dskit/grpcclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/grafana/mimir/pkg/util/globalerror"
+)
+
+// BreakerConfig configures the circuit breaker built by InstrumentWithBreaker.
+type BreakerConfig struct {
+	// WindowSize is the number of most recent requests considered when
+	// computing the failure ratio that trips the breaker.
+	WindowSize int
+	// MinRequestAmount is the minimum number of requests that must have been
+	// observed in the window before the breaker is allowed to trip, so that
+	// a handful of cold-start failures don't open the breaker prematurely.
+	MinRequestAmount int
+	// FailureRatio is the fraction of failed requests within the window
+	// at or above which the breaker trips to the open state.
+	FailureRatio float64
+	// OpenDuration is how long the breaker stays open before allowing a
+	// half-open probe through.
+	OpenDuration time.Duration
+	// HalfOpenProbes is the number of requests let through while half-open
+	// before deciding whether to close or re-open the breaker.
+	HalfOpenProbes int
+}
+
+// acceptableBreakerCodes are codes attributed to the caller rather than the
+// callee: they must never count as a failure towards tripping the breaker,
+// mirroring go-zero's breaker interceptor "acceptable errors" classification.
+var acceptableBreakerCodes = map[codes.Code]bool{
+	codes.InvalidArgument:    true,
+	codes.NotFound:           true,
+	codes.AlreadyExists:      true,
+	codes.FailedPrecondition: true,
+	codes.PermissionDenied:   true,
+	codes.Unauthenticated:    true,
+	codes.Canceled:           true,
+}
+
+// isBreakerFailure reports whether err should count against the circuit
+// breaker. Only server-attributable codes (Unavailable, Internal, DataLoss,
+// DeadlineExceeded, ResourceExhausted) count as failures; client-caused
+// codes never trip the breaker. An error that carries no gRPC status at all
+// also counts as a failure.
+func isBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return true
+	}
+	if acceptableBreakerCodes[st.Code()] {
+		return false
+	}
+	switch st.Code() {
+	case codes.Unavailable, codes.Internal, codes.DataLoss, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+// ErrCircuitBreakerOpen is the sentinel cause wrapped by the error returned
+// when a call is rejected because the circuit is open; detect it with
+// errors.Is. The error actually returned to callers also carries a gRPC
+// status (built by newCircuitBreakerOpenError), so that
+// globalerror.FromError() recognizes it without special-casing.
+var ErrCircuitBreakerOpen = errCircuitBreakerOpen{}
+
+type errCircuitBreakerOpen struct{}
+
+func (errCircuitBreakerOpen) Error() string { return "circuit breaker is open" }
+
+// newCircuitBreakerOpenError builds the error returned for a rejected call,
+// carrying a RetryInfo detail so well-behaved clients back off for
+// retryAfter before trying again.
+func newCircuitBreakerOpenError(retryAfter time.Duration) error {
+	return globalerror.NewErrorWithGRPCStatusDetails(
+		ErrCircuitBreakerOpen,
+		codes.Unavailable,
+		nil,
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)},
+	)
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// breaker is a sliding-window, failure-ratio circuit breaker for a single
+// (target, method) pair.
+type breaker struct {
+	cfg BreakerConfig
+
+	target string
+	method string
+
+	mtx         sync.Mutex
+	state       breakerState
+	results     []bool // ring buffer of the last WindowSize outcomes, true == failure
+	next        int
+	filled      int
+	openedAt    time.Time
+	halfOpenUse int
+
+	metrics *breakerMetrics
+}
+
+func newBreaker(cfg BreakerConfig, target, method string, metrics *breakerMetrics) *breaker {
+	windowSize := cfg.WindowSize
+	if windowSize <= 0 {
+		// A caller that forgets to set WindowSize would otherwise make
+		// results a zero-length ring buffer, panicking on the first record().
+		windowSize = 1
+	}
+	return &breaker{
+		cfg:     cfg,
+		target:  target,
+		method:  method,
+		state:   breakerClosed,
+		results: make([]bool, windowSize),
+		metrics: metrics,
+	}
+}
+
+// allow reports whether a request may proceed given the breaker's current
+// state, transitioning from open to half-open once OpenDuration has passed.
+func (b *breaker) allow() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cfg.OpenDuration {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		b.halfOpenUse = 0
+		fallthrough
+	case breakerHalfOpen:
+		if b.halfOpenUse >= b.cfg.HalfOpenProbes {
+			return false
+		}
+		b.halfOpenUse++
+		return true
+	default:
+		return true
+	}
+}
+
+// record registers the outcome of a request that was allowed through.
+func (b *breaker) record(failed bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.state == breakerHalfOpen {
+		if failed {
+			b.setState(breakerOpen)
+			b.openedAt = time.Now()
+		} else if b.halfOpenUse >= b.cfg.HalfOpenProbes {
+			b.setState(breakerClosed)
+			b.filled = 0
+			b.next = 0
+		}
+		return
+	}
+
+	b.results[b.next] = failed
+	b.next = (b.next + 1) % len(b.results)
+	if b.filled < len(b.results) {
+		b.filled++
+	}
+
+	if b.filled < b.cfg.MinRequestAmount {
+		return
+	}
+
+	failures := 0
+	for i := 0; i < b.filled; i++ {
+		if b.results[i] {
+			failures++
+		}
+	}
+	if float64(failures)/float64(b.filled) >= b.cfg.FailureRatio {
+		b.setState(breakerOpen)
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *breaker) setState(s breakerState) {
+	if b.state == s {
+		return
+	}
+	b.state = s
+	if b.metrics != nil {
+		b.metrics.state.WithLabelValues(b.target, b.method, s.String()).Inc()
+	}
+}
+
+// breakerRegistry holds one breaker per (target, method) pair.
+type breakerRegistry struct {
+	cfg     BreakerConfig
+	metrics *breakerMetrics
+
+	mtx      sync.Mutex
+	breakers map[string]*breaker
+}
+
+type breakerMetrics struct {
+	state *prometheus.CounterVec
+}
+
+func newBreakerMetrics(reg prometheus.Registerer) *breakerMetrics {
+	return &breakerMetrics{
+		state: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_client_circuit_breaker_transitions_total",
+			Help: "Number of circuit breaker state transitions, by target, method and state transitioned to.",
+		}, []string{"target", "method", "state"}),
+	}
+}
+
+func newBreakerRegistry(cfg BreakerConfig, metrics *breakerMetrics) *breakerRegistry {
+	return &breakerRegistry{
+		cfg:      cfg,
+		metrics:  metrics,
+		breakers: map[string]*breaker{},
+	}
+}
+
+func (r *breakerRegistry) get(target, method string) *breaker {
+	key := target + "|" + method
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	b, ok := r.breakers[key]
+	if !ok {
+		b = newBreaker(r.cfg, target, method, r.metrics)
+		r.breakers[key] = b
+	}
+	return b
+}
+
+// UnaryClientBreakerInterceptor returns a grpc.UnaryClientInterceptor that
+// trips a per-(target, method) circuit breaker on server-side failures, and
+// rejects calls with ErrCircuitBreakerOpen while the breaker is open.
+func UnaryClientBreakerInterceptor(cfg BreakerConfig, reg prometheus.Registerer) grpc.UnaryClientInterceptor {
+	return unaryBreakerInterceptor(cfg, newBreakerRegistry(cfg, newBreakerMetrics(reg)))
+}
+
+// StreamClientBreakerInterceptor is the streaming counterpart of
+// UnaryClientBreakerInterceptor: it guards stream creation and records a
+// failure if the stream fails to establish.
+func StreamClientBreakerInterceptor(cfg BreakerConfig, reg prometheus.Registerer) grpc.StreamClientInterceptor {
+	return streamBreakerInterceptor(cfg, newBreakerRegistry(cfg, newBreakerMetrics(reg)))
+}
+
+func unaryBreakerInterceptor(cfg BreakerConfig, registry *breakerRegistry) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		b := registry.get(cc.Target(), method)
+		if !b.allow() {
+			return newCircuitBreakerOpenError(cfg.OpenDuration)
+		}
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		b.record(isBreakerFailure(err))
+		return err
+	}
+}
+
+func streamBreakerInterceptor(cfg BreakerConfig, registry *breakerRegistry) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		b := registry.get(cc.Target(), method)
+		if !b.allow() {
+			return nil, newCircuitBreakerOpenError(cfg.OpenDuration)
+		}
+
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		b.record(isBreakerFailure(err))
+		return stream, err
+	}
+}
+
+// InstrumentWithBreaker is like dskit/grpcclient.Instrument, but additionally
+// installs a circuit breaker ahead of every call: once a target+method pair
+// sees enough server-side failures, subsequent calls fail fast with
+// ErrCircuitBreakerOpen instead of waiting out another RPC timeout. This
+// gives Mimir's internal RPCs (e.g. querier -> store-gateway,
+// distributor -> ingester) automatic backpressure.
+//
+// The unary and stream interceptors share a single breakerRegistry (and thus
+// a single set of metrics registered against reg): building one independently
+// per interceptor would register cortex_client_circuit_breaker_transitions_total
+// twice against the same registerer and panic.
+func InstrumentWithBreaker(requestDuration *prometheus.HistogramVec, breakerCfg BreakerConfig, reg prometheus.Registerer) ([]grpc.UnaryClientInterceptor, []grpc.StreamClientInterceptor) {
+	unary, stream := dskitgrpcclient.Instrument(requestDuration)
+
+	registry := newBreakerRegistry(breakerCfg, newBreakerMetrics(reg))
+	unary = append(unary, unaryBreakerInterceptor(breakerCfg, registry))
+	stream = append(stream, streamBreakerInterceptor(breakerCfg, registry))
+
+	return unary, stream
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package grpcclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsBreakerFailure(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"nil error is not a failure":                     {err: nil, expected: false},
+		"non-status error is a failure":                  {err: assert.AnError, expected: true},
+		"Unavailable counts as a failure":                {err: status.Error(codes.Unavailable, "x"), expected: true},
+		"Internal counts as a failure":                   {err: status.Error(codes.Internal, "x"), expected: true},
+		"DeadlineExceeded counts as a failure":           {err: status.Error(codes.DeadlineExceeded, "x"), expected: true},
+		"ResourceExhausted counts as a failure":          {err: status.Error(codes.ResourceExhausted, "x"), expected: true},
+		"InvalidArgument does not count as a failure":    {err: status.Error(codes.InvalidArgument, "x"), expected: false},
+		"NotFound does not count as a failure":           {err: status.Error(codes.NotFound, "x"), expected: false},
+		"FailedPrecondition does not count as a failure": {err: status.Error(codes.FailedPrecondition, "x"), expected: false},
+		"PermissionDenied does not count as a failure":   {err: status.Error(codes.PermissionDenied, "x"), expected: false},
+		"Canceled does not count as a failure":           {err: status.Error(codes.Canceled, "x"), expected: false},
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, data.expected, isBreakerFailure(data.err))
+		})
+	}
+}
+
+func TestBreaker_TripsAndRecovers(t *testing.T) {
+	cfg := BreakerConfig{
+		WindowSize:       4,
+		MinRequestAmount: 2,
+		FailureRatio:     0.5,
+		OpenDuration:     10 * time.Millisecond,
+		HalfOpenProbes:   1,
+	}
+	reg := prometheus.NewPedanticRegistry()
+	b := newBreaker(cfg, "target", "method", newBreakerMetrics(reg))
+
+	require.True(t, b.allow())
+	b.record(true)
+	require.True(t, b.allow())
+	b.record(true)
+
+	// Two failures out of two requests trips the breaker.
+	require.False(t, b.allow())
+	assert.Equal(t, float64(1), testutil.ToFloat64(b.metrics.state.WithLabelValues("target", "method", "open")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(b.metrics.state.WithLabelValues("target", "method", "half-open")))
+
+	time.Sleep(cfg.OpenDuration * 2)
+
+	// The breaker should now allow exactly one half-open probe.
+	require.True(t, b.allow())
+	require.False(t, b.allow())
+	assert.Equal(t, float64(1), testutil.ToFloat64(b.metrics.state.WithLabelValues("target", "method", "half-open")))
+
+	b.record(false)
+	assert.Equal(t, float64(1), testutil.ToFloat64(b.metrics.state.WithLabelValues("target", "method", "closed")))
+	require.True(t, b.allow())
+}
+
+func TestBreaker_HalfOpenFailureReopens(t *testing.T) {
+	cfg := BreakerConfig{
+		WindowSize:       4,
+		MinRequestAmount: 1,
+		FailureRatio:     0.5,
+		OpenDuration:     10 * time.Millisecond,
+		HalfOpenProbes:   1,
+	}
+	b := newBreaker(cfg, "target", "method", newBreakerMetrics(prometheus.NewPedanticRegistry()))
+
+	require.True(t, b.allow())
+	b.record(true)
+	require.False(t, b.allow())
+
+	time.Sleep(cfg.OpenDuration * 2)
+	require.True(t, b.allow())
+	b.record(true)
+
+	require.False(t, b.allow())
+}
+
+func TestNewBreaker_ZeroWindowSizeDoesNotPanic(t *testing.T) {
+	b := newBreaker(BreakerConfig{}, "target", "method", newBreakerMetrics(prometheus.NewPedanticRegistry()))
+
+	require.NotPanics(t, func() {
+		require.True(t, b.allow())
+		b.record(false)
+	})
+}
+
+func TestInstrumentWithBreaker_DoesNotDoubleRegisterMetrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	cfg := BreakerConfig{WindowSize: 1, MinRequestAmount: 1, FailureRatio: 0.5, OpenDuration: time.Second, HalfOpenProbes: 1}
+
+	require.NotPanics(t, func() {
+		InstrumentWithBreaker(prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "unused"}, []string{"method"}), cfg, reg)
+	})
+}
@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package grpcclient
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	dskitgrpcclient "github.com/grafana/dskit/grpcclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryConfig configures the retry interceptor built by
+// UnaryClientRetryInterceptor / InstrumentWithRetries.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of times an RPC is attempted,
+	// including the first, non-retried attempt.
+	MaxAttempts int
+	// BaseDelay is the backoff used for the first retry; every subsequent
+	// retry doubles it, up to MaxDelay, before applying full jitter.
+	BaseDelay time.Duration
+	// MaxDelay caps the computed backoff, before jitter is applied.
+	MaxDelay time.Duration
+	// PerCallTimeout bounds each individual attempt. The overall call is
+	// still governed by the caller's context deadline.
+	PerCallTimeout time.Duration
+	// RetryableMethods is the allowlist of full gRPC method names
+	// (e.g. "/cortex.Ingester/QueryStream") that may be retried. RPCs not in
+	// this allowlist are only ever attempted once. Any method in the map is
+	// retried as given; an allowlist built by NewRetryableMethods never
+	// contains Push, because it's not idempotent.
+	RetryableMethods map[string]bool
+}
+
+// retryableCodes are the codes worth retrying: transient conditions where a
+// second attempt has a reasonable chance of succeeding.
+var retryableCodes = map[codes.Code]bool{
+	codes.Unavailable:       true,
+	codes.DeadlineExceeded:  true,
+	codes.ResourceExhausted: true,
+}
+
+type retryMetrics struct {
+	retries *prometheus.CounterVec
+}
+
+func newRetryMetrics(reg prometheus.Registerer) *retryMetrics {
+	return &retryMetrics{
+		retries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_client_request_retries_total",
+			Help: "Number of times a gRPC client request was retried, by method and the code that triggered the retry.",
+		}, []string{"method", "code"}),
+	}
+}
+
+// UnaryClientRetryInterceptor returns a grpc.UnaryClientInterceptor that
+// retries idempotent RPCs (per cfg.RetryableMethods) on codes.Unavailable,
+// codes.DeadlineExceeded (per attempt only) and codes.ResourceExhausted,
+// using exponential backoff with full jitter. If the failed attempt's
+// status carries a google.rpc.RetryInfo detail (e.g. from a circuit breaker
+// or a rate limiter), its retry_delay is honored verbatim instead of the
+// computed backoff.
+//
+// The interceptor never retries once ctx.Err() != nil, so it composes
+// safely with an outer deadline: a chain of retries is always bounded by
+// the caller's own context. To compose with UnaryClientBreakerInterceptor so
+// that retries count as a single logical call for breaker purposes, the
+// breaker interceptor must be chained ahead of (outermost to) this one —
+// see InstrumentWithBreakerAndRetries. Chaining it the other way around
+// would have the breaker invoked once per attempt instead of once per
+// logical call, tripping it far earlier than a single failing call
+// justifies.
+func UnaryClientRetryInterceptor(cfg RetryConfig, reg prometheus.Registerer) grpc.UnaryClientInterceptor {
+	metrics := newRetryMetrics(reg)
+
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !cfg.RetryableMethods[method] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		var err error
+		for attempt := 0; attempt < maxAttempts(cfg); attempt++ {
+			callCtx, cancel := withPerCallTimeout(ctx, cfg.PerCallTimeout)
+			err = invoker(callCtx, method, req, reply, cc, opts...)
+			cancel()
+
+			if err == nil {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return err
+			}
+
+			code := status.Code(err)
+			if !retryableCodes[code] {
+				return err
+			}
+			if attempt == maxAttempts(cfg)-1 {
+				return err
+			}
+
+			metrics.retries.WithLabelValues(method, code.String()).Inc()
+
+			if !sleep(ctx, retryDelay(err, cfg, attempt)) {
+				return err
+			}
+		}
+		return err
+	}
+}
+
+// InstrumentWithRetries is like dskit/grpcclient.Instrument, but additionally
+// installs UnaryClientRetryInterceptor ahead of every call.
+func InstrumentWithRetries(requestDuration *prometheus.HistogramVec, retryCfg RetryConfig, reg prometheus.Registerer) ([]grpc.UnaryClientInterceptor, []grpc.StreamClientInterceptor) {
+	unary, stream := dskitgrpcclient.Instrument(requestDuration)
+	unary = append(unary, UnaryClientRetryInterceptor(retryCfg, reg))
+	return unary, stream
+}
+
+// InstrumentWithBreakerAndRetries is like dskit/grpcclient.Instrument, but
+// additionally installs both the circuit breaker and retry interceptors,
+// chained in the only order that makes them compose correctly: the breaker
+// interceptor is added before the retry interceptor, so that
+// grpc.WithChainUnaryInterceptor (which treats its first argument as
+// outermost) invokes the breaker once per logical call, with the retry
+// interceptor's internal attempt loop nested entirely inside that single
+// invocation.
+//
+// As in InstrumentWithBreaker, the unary and stream breaker interceptors
+// share a single breakerRegistry so cortex_client_circuit_breaker_transitions_total
+// is only registered against reg once.
+func InstrumentWithBreakerAndRetries(requestDuration *prometheus.HistogramVec, breakerCfg BreakerConfig, retryCfg RetryConfig, reg prometheus.Registerer) ([]grpc.UnaryClientInterceptor, []grpc.StreamClientInterceptor) {
+	unary, stream := dskitgrpcclient.Instrument(requestDuration)
+
+	registry := newBreakerRegistry(breakerCfg, newBreakerMetrics(reg))
+	unary = append(unary, unaryBreakerInterceptor(breakerCfg, registry), UnaryClientRetryInterceptor(retryCfg, reg))
+	stream = append(stream, streamBreakerInterceptor(breakerCfg, registry))
+
+	return unary, stream
+}
+
+// NewRetryableMethods builds a RetryConfig.RetryableMethods allowlist from a
+// list of full gRPC method names (e.g. "/cortex.Ingester/QueryStream"),
+// always excluding any method named Push: pushing samples is not idempotent
+// and must never be retried blindly, regardless of what the caller passes
+// in.
+func NewRetryableMethods(methods ...string) map[string]bool {
+	allowlist := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		if strings.HasSuffix(method, "/Push") {
+			continue
+		}
+		allowlist[method] = true
+	}
+	return allowlist
+}
+
+func maxAttempts(cfg RetryConfig) int {
+	if cfg.MaxAttempts <= 0 {
+		return 1
+	}
+	return cfg.MaxAttempts
+}
+
+func withPerCallTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// retryDelay returns how long to wait before the next attempt: the server's
+// own RetryInfo.retry_delay if present, otherwise exponential backoff with
+// full jitter seeded from cfg.BaseDelay/cfg.MaxDelay.
+func retryDelay(err error, cfg RetryConfig, attempt int) time.Duration {
+	if d, ok := retryInfoDelay(err); ok {
+		return d
+	}
+
+	backoff := cfg.BaseDelay << attempt
+	if cfg.MaxDelay > 0 && backoff > cfg.MaxDelay {
+		backoff = cfg.MaxDelay
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff)))
+}
+
+func retryInfoDelay(err error) (time.Duration, bool) {
+	stat, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+	for _, d := range stat.Details() {
+		if retryInfo, ok := d.(*errdetails.RetryInfo); ok && retryInfo.RetryDelay != nil {
+			return retryInfo.RetryDelay.AsDuration(), true
+		}
+	}
+	return 0, false
+}
+
+// sleep waits for d or until ctx is done, returning false in the latter
+// case so the caller can give up without retrying further.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package grpcclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestNewRetryableMethods_excludesPush(t *testing.T) {
+	allowlist := NewRetryableMethods("/cortex.Ingester/Push", "/cortex.Ingester/QueryStream")
+
+	assert.False(t, allowlist["/cortex.Ingester/Push"])
+	assert.True(t, allowlist["/cortex.Ingester/QueryStream"])
+}
+
+func TestUnaryClientRetryInterceptor(t *testing.T) {
+	const method = "/cortex.Ingester/QueryStream"
+	cfg := RetryConfig{
+		MaxAttempts:      3,
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         5 * time.Millisecond,
+		RetryableMethods: map[string]bool{method: true},
+	}
+
+	t.Run("does not retry a method outside the allowlist", func(t *testing.T) {
+		calls := 0
+		invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			calls++
+			return status.Error(codes.Unavailable, "down")
+		}
+
+		interceptor := UnaryClientRetryInterceptor(cfg, prometheus.NewPedanticRegistry())
+		err := interceptor(context.Background(), "/cortex.Ingester/Push", nil, nil, nil, invoker)
+
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("retries a retryable code up to MaxAttempts then gives up", func(t *testing.T) {
+		calls := 0
+		invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			calls++
+			return status.Error(codes.Unavailable, "down")
+		}
+
+		interceptor := UnaryClientRetryInterceptor(cfg, prometheus.NewPedanticRegistry())
+		err := interceptor(context.Background(), method, nil, nil, nil, invoker)
+
+		require.Error(t, err)
+		assert.Equal(t, cfg.MaxAttempts, calls)
+	})
+
+	t.Run("does not retry a non-retryable code", func(t *testing.T) {
+		calls := 0
+		invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			calls++
+			return status.Error(codes.InvalidArgument, "bad request")
+		}
+
+		interceptor := UnaryClientRetryInterceptor(cfg, prometheus.NewPedanticRegistry())
+		err := interceptor(context.Background(), method, nil, nil, nil, invoker)
+
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("succeeds without exhausting attempts once the call succeeds", func(t *testing.T) {
+		calls := 0
+		invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			calls++
+			if calls < 2 {
+				return status.Error(codes.Unavailable, "down")
+			}
+			return nil
+		}
+
+		interceptor := UnaryClientRetryInterceptor(cfg, prometheus.NewPedanticRegistry())
+		err := interceptor(context.Background(), method, nil, nil, nil, invoker)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("stops retrying once the caller's context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		calls := 0
+		invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			calls++
+			cancel()
+			return status.Error(codes.Unavailable, "down")
+		}
+
+		interceptor := UnaryClientRetryInterceptor(cfg, prometheus.NewPedanticRegistry())
+		err := interceptor(ctx, method, nil, nil, nil, invoker)
+
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("honors the server's RetryInfo.retry_delay verbatim", func(t *testing.T) {
+		calls := 0
+		var secondAttemptAt time.Time
+		firstAttemptAt := time.Now()
+
+		stat, err := status.New(codes.Unavailable, "down").WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(20 * time.Millisecond),
+		})
+		require.NoError(t, err)
+
+		invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			calls++
+			if calls == 1 {
+				return stat.Err()
+			}
+			secondAttemptAt = time.Now()
+			return nil
+		}
+
+		interceptor := UnaryClientRetryInterceptor(cfg, prometheus.NewPedanticRegistry())
+		err = interceptor(context.Background(), method, nil, nil, nil, invoker)
+
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, secondAttemptAt.Sub(firstAttemptAt), 20*time.Millisecond)
+	})
+}
+
+// TestBreakerSeesOneRecordPerLogicalCall is a regression test for the
+// correct composition order between the breaker and retry interceptors: as
+// documented on InstrumentWithBreakerAndRetries, the breaker must be chained
+// ahead of (outer to) the retry interceptor so that a retried call only
+// counts once against the breaker.
+func TestBreakerSeesOneRecordPerLogicalCall(t *testing.T) {
+	const method = "/cortex.Ingester/QueryStream"
+	retryCfg := RetryConfig{
+		MaxAttempts:      3,
+		BaseDelay:        time.Millisecond,
+		RetryableMethods: map[string]bool{method: true},
+	}
+	breakerCfg := BreakerConfig{
+		WindowSize:       10,
+		MinRequestAmount: 10,
+		FailureRatio:     0.5,
+		OpenDuration:     time.Second,
+		HalfOpenProbes:   1,
+	}
+
+	cc, err := grpc.Dial("passthrough:///fake", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+
+	attempts := 0
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		attempts++
+		if attempts < 3 {
+			return status.Error(codes.Unavailable, "down")
+		}
+		return nil
+	}
+
+	retryInterceptor := UnaryClientRetryInterceptor(retryCfg, prometheus.NewPedanticRegistry())
+	registry := newBreakerRegistry(breakerCfg, newBreakerMetrics(prometheus.NewPedanticRegistry()))
+	breakerInterceptor := unaryBreakerInterceptor(breakerCfg, registry)
+
+	// Chained with the breaker outermost: its invoker IS the retry
+	// interceptor, so the retry loop's 3 attempts are invisible to it.
+	err = breakerInterceptor(context.Background(), method, nil, nil, cc,
+		func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return retryInterceptor(ctx, method, req, reply, cc, invoker)
+		})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 1, registry.get(cc.Target(), method).filled)
+}
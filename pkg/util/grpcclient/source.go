@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package grpcclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/globalerror"
+)
+
+// NewSourceAwareRequestDuration registers and returns a histogram recording
+// client request duration with a "source" label, so that
+// cortex_client_request_duration_seconds_by_source{source="downstream"} can
+// be told apart from failures attributable to the immediate peer. This is a
+// separate metric from the cortex_client_request_duration_seconds histogram
+// fed to dskit's grpcclient.Instrument: that one has its own, incompatible
+// label set (maintained by weaveworks' middleware.UnaryClientInstrumentInterceptor),
+// so the "source" dimension can't simply be added onto it.
+func NewSourceAwareRequestDuration(reg prometheus.Registerer) *prometheus.HistogramVec {
+	return promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cortex_client_request_duration_seconds_by_source",
+		Help:    "Time spent doing gRPC client requests, labelled by the source responsible for a failure.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "source"})
+}
+
+// SourceAwareUnaryClientInterceptor returns a grpc.UnaryClientInterceptor
+// that records request duration under requestDuration (built by
+// NewSourceAwareRequestDuration), labelling the "source" dimension from the
+// returned status's ErrorDetails.Source. This mirrors the "status source"
+// middleware pattern from the Grafana plugin SDK: a component like the
+// querier that fans out to store-gateways can record a store-gateway's bug,
+// or an object-store timeout surfaced through it, as "downstream" instead of
+// indistinguishably blaming the immediate peer.
+func SourceAwareUnaryClientInterceptor(requestDuration *prometheus.HistogramVec) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		requestDuration.WithLabelValues(method, sourceLabel(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// sourceLabel returns "downstream" if err is attributed to a downstream
+// peer rather than the one that was just called directly, and "" otherwise.
+func sourceLabel(err error) string {
+	if err == nil {
+		return ""
+	}
+	reconstructed, ok := globalerror.FromError(err)
+	if !ok || reconstructed.GetSource() != mimirpb.SOURCE_DOWNSTREAM {
+		return ""
+	}
+	return "downstream"
+}
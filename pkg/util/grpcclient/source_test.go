@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package grpcclient
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/globalerror"
+)
+
+func TestSourceLabel(t *testing.T) {
+	t.Run("nil error has no source label", func(t *testing.T) {
+		assert.Equal(t, "", sourceLabel(nil))
+	})
+
+	t.Run("an error with no ErrorDetails has no source label", func(t *testing.T) {
+		err := globalerror.NewInternal("boom")
+		assert.Equal(t, "", sourceLabel(err))
+	})
+
+	t.Run("a downstream-sourced error is labelled downstream", func(t *testing.T) {
+		err := globalerror.NewInternal("store-gateway exploded").WithSource(mimirpb.SOURCE_DOWNSTREAM)
+		assert.Equal(t, "downstream", sourceLabel(err))
+	})
+
+	t.Run("a client-sourced error has no source label", func(t *testing.T) {
+		err := globalerror.NewInternal("bad request shape").WithSource(mimirpb.SOURCE_CLIENT)
+		assert.Equal(t, "", sourceLabel(err))
+	})
+}
+
+func TestNewSourceAwareRequestDuration_doesNotCollideWithDskitMetric(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+
+	// The histogram dskitgrpcclient.Instrument expects the caller to supply
+	// and feed to weaveworks' UnaryClientInstrumentInterceptor, with its own
+	// label set. Registering both under the same registry must not panic
+	// with "duplicate metrics collector registration attempted" nor hit an
+	// inconsistent-cardinality panic at observe time.
+	dskitRequestDuration := promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+		Name: "cortex_client_request_duration_seconds",
+	}, []string{"protocol", "route", "status_code", "ws"})
+
+	sourceAwareRequestDuration := NewSourceAwareRequestDuration(reg)
+
+	require.NotPanics(t, func() {
+		dskitRequestDuration.WithLabelValues("grpc", "/cortex.Ingester/Push", "200", "false").Observe(0.1)
+		sourceAwareRequestDuration.WithLabelValues("/cortex.Ingester/Push", "downstream").Observe(0.1)
+	})
+}
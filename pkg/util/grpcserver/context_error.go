@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/grafana/dskit/grpcutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/grafana/mimir/pkg/util/globalerror"
+)
+
+// NewContextErrorUnaryServerInterceptor returns a grpc.UnaryServerInterceptor
+// that normalizes context cancellation/deadline errors into the matching
+// gRPC status, analogous to Gitaly's statushandler/cancelhandler.
+//
+// If the handler's error doesn't already carry a gRPC status but is (or
+// wraps) context.Canceled or context.DeadlineExceeded, it's rewritten into
+// codes.Canceled / codes.DeadlineExceeded. The same happens if the error does
+// carry a status but it's codes.Unknown: the request context's error takes
+// precedence in both cases, since codes.Unknown (like no status at all) means
+// the error wasn't deliberately classified — this catches the common case
+// where a downstream call surfaced as codes.Unknown because the deadline
+// fired mid-flight.
+//
+// Wiring this in means call sites no longer need to call
+// globalerror.WrapGrpcContextError themselves, and
+// grpc_server_handled_total{grpc_code=...} distinguishes cancellations from
+// real internal errors.
+func NewContextErrorUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		return resp, normalizeContextError(ctx, err)
+	}
+}
+
+// NewContextErrorStreamServerInterceptor is the streaming counterpart of
+// NewContextErrorUnaryServerInterceptor.
+func NewContextErrorStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+		return normalizeContextError(ss.Context(), err)
+	}
+}
+
+func normalizeContextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if stat, ok := grpcutil.ErrorToStatus(err); ok {
+		switch stat.Code() {
+		case codes.Canceled, codes.DeadlineExceeded:
+			return globalerror.WrapGrpcContextError(err)
+		case codes.Unknown:
+			// codes.Unknown means the error wasn't deliberately classified
+			// (e.g. a downstream call returned it because the deadline fired
+			// mid-flight): fall through to the ctx.Err() precedence below,
+			// same as if it carried no status at all.
+		default:
+			return err
+		}
+	}
+
+	// The handler's error carries no status, or carries codes.Unknown, while
+	// the request context itself is done: prefer the context's error, it's
+	// the real cause, and build a proper status-carrying error so the client
+	// sees the right code instead of codes.Unknown. If the context isn't done
+	// but the error itself is (or wraps) a context error, use that instead.
+	ctxErr := ctx.Err()
+	if ctxErr == nil {
+		switch {
+		case errors.Is(err, context.Canceled):
+			ctxErr = context.Canceled
+		case errors.Is(err, context.DeadlineExceeded):
+			ctxErr = context.DeadlineExceeded
+		}
+	}
+	switch ctxErr {
+	case context.Canceled:
+		return globalerror.WrapGrpcContextError(globalerror.NewCanceled(err.Error()).WithCause(err))
+	case context.DeadlineExceeded:
+		return globalerror.WrapGrpcContextError(globalerror.NewDeadlineExceeded(err.Error()).WithCause(err))
+	default:
+		return err
+	}
+}
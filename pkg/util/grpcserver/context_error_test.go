@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package grpcserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNormalizeContextError(t *testing.T) {
+	t.Run("nil error is returned unchanged", func(t *testing.T) {
+		assert.NoError(t, normalizeContextError(context.Background(), nil))
+	})
+
+	t.Run("a plain error is left alone when the context isn't done", func(t *testing.T) {
+		orig := errors.New("boom")
+		assert.Equal(t, orig, normalizeContextError(context.Background(), orig))
+	})
+
+	t.Run("a plain error is rewritten to codes.Canceled when the context was canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		orig := errors.New("downstream call returned Unknown")
+		got := normalizeContextError(ctx, orig)
+
+		assert.ErrorIs(t, got, context.Canceled)
+		st, ok := status.FromError(got)
+		require.True(t, ok)
+		assert.Equal(t, codes.Canceled, st.Code())
+	})
+
+	t.Run("a plain error is rewritten to codes.DeadlineExceeded when the deadline fired", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+
+		orig := errors.New("downstream call returned Unknown")
+		got := normalizeContextError(ctx, orig)
+
+		assert.ErrorIs(t, got, context.DeadlineExceeded)
+		st, ok := status.FromError(got)
+		require.True(t, ok)
+		assert.Equal(t, codes.DeadlineExceeded, st.Code())
+	})
+
+	t.Run("an already status-carrying Canceled error is wrapped for errors.Is", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		orig := status.Error(codes.Canceled, context.Canceled.Error())
+		got := normalizeContextError(ctx, orig)
+
+		assert.ErrorIs(t, got, context.Canceled)
+		st, ok := status.FromError(got)
+		require.True(t, ok)
+		assert.Equal(t, codes.Canceled, st.Code())
+	})
+
+	t.Run("an unrelated status-carrying error is left alone even if the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		orig := status.Error(codes.Internal, "real bug")
+		assert.Equal(t, orig, normalizeContextError(ctx, orig))
+	})
+
+	t.Run("a status-carrying codes.Unknown error defers to ctx.Err() when the context was canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		orig := status.Error(codes.Unknown, "downstream call returned Unknown")
+		got := normalizeContextError(ctx, orig)
+
+		assert.ErrorIs(t, got, context.Canceled)
+		st, ok := status.FromError(got)
+		require.True(t, ok)
+		assert.Equal(t, codes.Canceled, st.Code())
+	})
+
+	t.Run("a status-carrying codes.Unknown error is left alone when the context isn't done", func(t *testing.T) {
+		orig := status.Error(codes.Unknown, "genuinely unclassified")
+		assert.Equal(t, orig, normalizeContextError(context.Background(), orig))
+	})
+}